@@ -0,0 +1,410 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"log"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/ghodss/yaml"
+)
+
+// mapSections are the top-level swagger sections an extands file may
+// populate as a nested map, keyed by security scheme name, parameter name or
+// response name respectively.
+var mapSections = []string{"securityDefinitions", "parameters", "responses"}
+
+// appendExtands reads, validates and merges a single extands file into the
+// matching section of templateMap. Entries under the section keys below
+// target that section directly; a `tags` entry is appended to the `tags`
+// array; any `x-`-prefixed key is copied to the document root as a vendor
+// extension; everything else is treated as a model definition, which keeps
+// the original (definitions-only) behaviour working unchanged. The reserved
+// `entries` array lets a single entry target one section explicitly via a
+// `kind` field instead, for files that would rather stay flat than be
+// restructured under each section's key (see mergeExtandsEntries).
+//
+// definitionOrigins tracks which extands file first defined each model in
+// `definitions`, so that a later file redefining the same model is reported
+// instead of silently winning.
+func appendExtands(templateMap map[string]interface{}, extandsFilePath string, definitionOrigins map[string]string) error {
+	extandsMap, err := readExtandsFile(extandsFilePath)
+	if err != nil {
+		return err
+	}
+	if err = validateExtandsDocument(extandsMap, extandsFilePath); err != nil {
+		return err
+	}
+
+	definitionsMap := templateMap["definitions"].(map[string]interface{})
+	for key, content := range extandsMap {
+		switch {
+		case key == "definitions":
+			if err = mergeDefinitions(definitionsMap, content, extandsFilePath, definitionOrigins); err != nil {
+				return err
+			}
+		case contains(mapSections, key):
+			section, _ := templateMap[key].(map[string]interface{})
+			if section == nil {
+				section = map[string]interface{}{}
+				templateMap[key] = section
+			}
+			mergeMapSection(section, content)
+		case key == "tags":
+			tags, _ := templateMap["tags"].([]interface{})
+			newTags, ok := content.([]interface{})
+			if !ok {
+				return errors.New("extands file " + extandsFilePath + ": tags must be an array")
+			}
+			templateMap["tags"] = append(tags, newTags...)
+		case key == "entries":
+			newEntries, ok := content.([]interface{})
+			if !ok {
+				return errors.New("extands file " + extandsFilePath + ": entries must be an array")
+			}
+			if err = mergeExtandsEntries(templateMap, definitionsMap, newEntries, extandsFilePath, definitionOrigins); err != nil {
+				return err
+			}
+		case strings.HasPrefix(key, "x-"):
+			templateMap[key] = content
+		default:
+			if err = mergeDefinitions(definitionsMap, map[string]interface{}{key: content}, extandsFilePath, definitionOrigins); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// mergeExtandsEntries merges the `entries` array: each entry is an object
+// with a `kind` naming the section it targets ("definitions",
+// "securityDefinitions", "parameters", "responses", "tags", or an `x-`
+// vendor extension), a `name` (required by every kind but "tags" and `x-`
+// vendor extensions, which do not use it), and a
+// `content` value. This lets one entry redefine or add to a single section
+// without nesting the whole extands file under that section's key.
+func mergeExtandsEntries(templateMap map[string]interface{}, definitionsMap map[string]interface{}, entries []interface{}, extandsFilePath string, definitionOrigins map[string]string) error {
+	for i, raw := range entries {
+		entry, ok := raw.(map[string]interface{})
+		if !ok {
+			return errors.New("extands file " + extandsFilePath + ": entries/" + strconv.Itoa(i) + " must be an object")
+		}
+		kind, _ := entry["kind"].(string)
+		name, _ := entry["name"].(string)
+		content := entry["content"]
+
+		switch {
+		case kind == "definitions":
+			if name == "" {
+				return errors.New("extands file " + extandsFilePath + ": entries/" + strconv.Itoa(i) + ": definitions entry requires a name")
+			}
+			if err := mergeDefinitions(definitionsMap, map[string]interface{}{name: content}, extandsFilePath, definitionOrigins); err != nil {
+				return err
+			}
+		case contains(mapSections, kind):
+			if name == "" {
+				return errors.New("extands file " + extandsFilePath + ": entries/" + strconv.Itoa(i) + ": " + kind + " entry requires a name")
+			}
+			section, _ := templateMap[kind].(map[string]interface{})
+			if section == nil {
+				section = map[string]interface{}{}
+				templateMap[kind] = section
+			}
+			section[name] = content
+		case kind == "tags":
+			tags, _ := templateMap["tags"].([]interface{})
+			templateMap["tags"] = append(tags, content)
+		case strings.HasPrefix(kind, "x-"):
+			templateMap[kind] = content
+		default:
+			return errors.New("extands file " + extandsFilePath + ": entries/" + strconv.Itoa(i) + ": unknown kind " + kind)
+		}
+	}
+	return nil
+}
+
+// mergeDefinitions merges content, which must be a JSON object keyed by
+// model name, into dest, failing if a model name was already defined by an
+// earlier extands file.
+func mergeDefinitions(dest map[string]interface{}, content interface{}, extandsFilePath string, definitionOrigins map[string]string) error {
+	entries, ok := content.(map[string]interface{})
+	if !ok {
+		return nil
+	}
+	for name, value := range entries {
+		if origin, exist := definitionOrigins[name]; exist && origin != extandsFilePath {
+			return errors.New("definition " + name + " redefined in file " + extandsFilePath + " (first defined in " + origin + ")")
+		}
+		definitionOrigins[name] = extandsFilePath
+		dest[name] = value
+	}
+	return nil
+}
+
+// mergeMapSection merges content, which must be a JSON object, into dest
+// key by key.
+func mergeMapSection(dest map[string]interface{}, content interface{}) {
+	entries, ok := content.(map[string]interface{})
+	if !ok {
+		return
+	}
+	for name, value := range entries {
+		dest[name] = value
+	}
+}
+
+func contains(values []string, target string) bool {
+	for _, value := range values {
+		if value == target {
+			return true
+		}
+	}
+	return false
+}
+
+func readExtandsFile(extandsFilePath string) (map[string]interface{}, error) {
+	extantsFileBytes, err := ioutil.ReadFile(extandsFilePath)
+	if err != nil {
+		log.Println("open extands file fail")
+		return nil, err
+	}
+
+	var extandsMap map[string]interface{}
+	if isYAMLExtandsFile(extandsFilePath, extantsFileBytes) {
+		// ghodss/yaml converts YAML to JSON before unmarshalling, so valid
+		// JSON is accepted here too, and the result is a plain JSON-style
+		// map[string]interface{} like the json.Unmarshal path below.
+		err = yaml.Unmarshal(extantsFileBytes, &extandsMap)
+	} else {
+		err = json.Unmarshal(extantsFileBytes, &extandsMap)
+	}
+	if err != nil {
+		log.Println("extands file unmarshal fail: " + extandsFilePath)
+		return nil, err
+	}
+	return extandsMap, nil
+}
+
+// isYAMLExtandsFile decides whether an extands file should be parsed as
+// YAML rather than JSON: by its `.yaml`/`.yml` extension, or, failing that,
+// by sniffing whether its first non-whitespace byte opens a JSON object.
+func isYAMLExtandsFile(extandsFilePath string, content []byte) bool {
+	switch strings.ToLower(filepath.Ext(extandsFilePath)) {
+	case ".yaml", ".yml":
+		return true
+	case ".json":
+		return false
+	}
+
+	trimmed := strings.TrimSpace(string(content))
+	return !strings.HasPrefix(trimmed, "{")
+}
+
+// securitySchemeTypes are the `type` values Swagger 2.0 allows in a
+// Security Scheme Object.
+var securitySchemeTypes = []string{"basic", "apiKey", "oauth2"}
+
+// validateExtandsDocument checks extandsMap against a small meta-schema
+// covering the top-level shape an extands file may have (the recognized
+// section keys, `x-` vendor extensions, and otherwise a map of bare model
+// names) and the Swagger 2.0 Schema Object shape of whatever ends up in
+// `definitions`. It fails fast: the first violation found is returned,
+// prefixed with the offending file path and a JSON pointer to the value.
+func validateExtandsDocument(extandsMap map[string]interface{}, extandsFilePath string) error {
+	for key, content := range extandsMap {
+		var err error
+		switch {
+		case key == "definitions":
+			err = validateDefinitionsSection(content, "/definitions")
+		case key == "securityDefinitions":
+			err = validateSecurityDefinitionsSection(content, "/securityDefinitions")
+		case key == "parameters" || key == "responses":
+			err = validateObjectOfObjects(content, "/"+key)
+		case key == "tags":
+			err = validateTagsSection(content, "/tags")
+		case key == "entries":
+			err = validateEntriesSection(content, "/entries")
+		case strings.HasPrefix(key, "x-"):
+			// vendor extensions carry arbitrary content, nothing to validate
+		default:
+			err = validateSchemaObject(content, "/"+key)
+		}
+		if err != nil {
+			return extandsValidationError(extandsFilePath, err)
+		}
+	}
+	return nil
+}
+
+func extandsValidationError(extandsFilePath string, err error) error {
+	return errors.New(extandsFilePath + ": " + err.Error())
+}
+
+func validateDefinitionsSection(content interface{}, pointer string) error {
+	entries, ok := content.(map[string]interface{})
+	if !ok {
+		return errors.New(pointer + ": must be an object of model name to schema")
+	}
+	for name, schema := range entries {
+		if err := validateSchemaObject(schema, pointer+"/"+name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func validateSchemaObject(value interface{}, pointer string) error {
+	schema, ok := value.(map[string]interface{})
+	if !ok {
+		return errors.New(pointer + ": must be a Schema Object (JSON object)")
+	}
+	if ref, exist := schema["$ref"]; exist {
+		if _, ok := ref.(string); !ok {
+			return errors.New(pointer + "/$ref: must be a string")
+		}
+	}
+	if typ, exist := schema["type"]; exist {
+		if _, ok := typ.(string); !ok {
+			return errors.New(pointer + "/type: must be a string")
+		}
+	}
+	if properties, exist := schema["properties"]; exist {
+		propertiesMap, ok := properties.(map[string]interface{})
+		if !ok {
+			return errors.New(pointer + "/properties: must be an object")
+		}
+		for name, property := range propertiesMap {
+			if err := validateSchemaObject(property, pointer+"/properties/"+name); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func validateSecurityDefinitionsSection(content interface{}, pointer string) error {
+	entries, ok := content.(map[string]interface{})
+	if !ok {
+		return errors.New(pointer + ": must be an object of scheme name to Security Scheme Object")
+	}
+	for name, raw := range entries {
+		if err := validateSecurityScheme(raw, pointer+"/"+name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// validateSecurityScheme checks a single Security Scheme Object, the unit
+// validateSecurityDefinitionsSection applies per entry of its map.
+func validateSecurityScheme(value interface{}, pointer string) error {
+	scheme, ok := value.(map[string]interface{})
+	if !ok {
+		return errors.New(pointer + ": must be a Security Scheme Object (JSON object)")
+	}
+	typ, ok := scheme["type"].(string)
+	if !ok || !contains(securitySchemeTypes, typ) {
+		return errors.New(pointer + "/type: must be one of " + strings.Join(securitySchemeTypes, ", "))
+	}
+	return nil
+}
+
+func validateObjectOfObjects(content interface{}, pointer string) error {
+	entries, ok := content.(map[string]interface{})
+	if !ok {
+		return errors.New(pointer + ": must be an object")
+	}
+	for name, value := range entries {
+		if _, ok := value.(map[string]interface{}); !ok {
+			return errors.New(pointer + "/" + name + ": must be an object")
+		}
+	}
+	return nil
+}
+
+// entryKinds are the `kind` values an `entries` element may target, on top
+// of an `x-`-prefixed vendor extension kind.
+var entryKinds = append([]string{"definitions", "tags"}, mapSections...)
+
+// validateEntriesSection checks the `entries` array: each element must be
+// an object with a recognized `kind` (one of entryKinds, or `x-`-prefixed),
+// and a `name` unless its kind is "tags" or `x-`-prefixed.
+func validateEntriesSection(content interface{}, pointer string) error {
+	entries, ok := content.([]interface{})
+	if !ok {
+		return errors.New(pointer + ": must be an array of entry objects")
+	}
+	for i, raw := range entries {
+		entryPointer := pointer + "/" + strconv.Itoa(i)
+		entry, ok := raw.(map[string]interface{})
+		if !ok {
+			return errors.New(entryPointer + ": must be an object")
+		}
+		kind, ok := entry["kind"].(string)
+		if !ok || (!contains(entryKinds, kind) && !strings.HasPrefix(kind, "x-")) {
+			return errors.New(entryPointer + "/kind: must be one of " + strings.Join(entryKinds, ", ") + ", or an x- vendor extension")
+		}
+		if kind != "tags" && !strings.HasPrefix(kind, "x-") {
+			if _, ok := entry["name"].(string); !ok {
+				return errors.New(entryPointer + "/name: must be a string")
+			}
+		}
+		if err := validateEntryContent(kind, entry["content"], entryPointer+"/content"); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// validateEntryContent validates a single entries[i].content value against
+// the same rules validateExtandsDocument applies to that section when it's
+// addressed by its top-level key, so an entries-shaped extands file rejects
+// malformed content exactly as early as the equivalent top-level-key file
+// would.
+func validateEntryContent(kind string, content interface{}, pointer string) error {
+	switch {
+	case kind == "definitions":
+		return validateSchemaObject(content, pointer)
+	case kind == "securityDefinitions":
+		return validateSecurityScheme(content, pointer)
+	case kind == "parameters" || kind == "responses":
+		if _, ok := content.(map[string]interface{}); !ok {
+			return errors.New(pointer + ": must be an object")
+		}
+		return nil
+	case kind == "tags":
+		return validateTagObject(content, pointer)
+	case strings.HasPrefix(kind, "x-"):
+		return nil
+	}
+	return nil
+}
+
+func validateTagsSection(content interface{}, pointer string) error {
+	tags, ok := content.([]interface{})
+	if !ok {
+		return errors.New(pointer + ": must be an array of Tag Objects")
+	}
+	for i, raw := range tags {
+		if err := validateTagObject(raw, pointer+"/"+strconv.Itoa(i)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// validateTagObject checks a single Tag Object, the unit validateTagsSection
+// applies per element of its array.
+func validateTagObject(value interface{}, pointer string) error {
+	tag, ok := value.(map[string]interface{})
+	if !ok {
+		return errors.New(pointer + ": must be a Tag Object (JSON object)")
+	}
+	if _, ok := tag["name"].(string); !ok {
+		return errors.New(pointer + "/name: must be a string")
+	}
+	return nil
+}
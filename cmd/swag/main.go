@@ -9,11 +9,13 @@ import (
 	"os"
 	"strings"
 
+	"github.com/ghodss/yaml"
 	"github.com/urfave/cli/v2"
 
 	"github.com/swaggo/swag"
 	"github.com/swaggo/swag/format"
 	"github.com/zhangsenjun/swag-test/gen"
+	"github.com/zhangsenjun/swag-test/internal/openapi3"
 )
 
 const (
@@ -36,6 +38,7 @@ const (
 	parseGoListFlag       = "parseGoList"
 	quietFlag             = "quiet"
 	extandFilesFlag       = "extandFiles"
+	openapi3Flag          = "openapi3"
 )
 
 var initFlags = []cli.Flag{
@@ -131,6 +134,10 @@ var initFlags = []cli.Flag{
 		Value: true,
 		Usage: "Parse dependency via 'go list'",
 	},
+	&cli.BoolFlag{
+		Name:  openapi3Flag,
+		Usage: "Also write openapi.json/openapi.yaml, converted from the generated Swagger 2.0 document",
+	},
 }
 
 var updateFlags = append(
@@ -139,7 +146,7 @@ var updateFlags = append(
 			Name:    extandFilesFlag,
 			Value:   "./docs/common/extands.json",
 			Aliases: []string{"efs"},
-			Usage:   "Use of multiple files `|` Split. Defaults path is: ./docs/common/extands.json ",
+			Usage:   "Use of multiple files `|` Split, JSON or YAML. Defaults path is: ./docs/common/extands.json ",
 		},
 	},
 	initFlags...,
@@ -163,7 +170,7 @@ func initAction(ctx *cli.Context) error {
 		logger = log.New(ioutil.Discard, "", log.LstdFlags)
 	}
 
-	return gen.New().Build(&gen.Config{
+	err := gen.New().Build(&gen.Config{
 		SearchDir:           ctx.String(searchDirFlag),
 		Excludes:            ctx.String(excludeFlag),
 		MainAPIFile:         ctx.String(generalInfoFlag),
@@ -183,6 +190,14 @@ func initAction(ctx *cli.Context) error {
 		ParseGoList:         ctx.Bool(parseGoListFlag),
 		Debugger:            logger,
 	})
+	if err != nil {
+		return err
+	}
+
+	if ctx.Bool(openapi3Flag) {
+		return writeOpenAPI3FromSwaggerFile(ctx.String(outputFlag))
+	}
+	return nil
 }
 
 func updateAction(ctx *cli.Context) error {
@@ -192,7 +207,7 @@ func updateAction(ctx *cli.Context) error {
 	}
 	log.Println("swag init executed successfully")
 	log.Println(ctx.String(extandFilesFlag))
-	err = updateData(ctx.String(outputFlag), ctx.String(extandFilesFlag))
+	err = updateData(ctx.String(outputFlag), ctx.String(extandFilesFlag), ctx.Bool(openapi3Flag))
 	if err != nil {
 		return err
 	}
@@ -220,6 +235,13 @@ func main() {
 			Action:  updateAction,
 			Flags:   updateFlags,
 		},
+		{
+			Name:    "watch",
+			Aliases: []string{"w"},
+			Usage:   "re-run init/update whenever source or extands files change",
+			Action:  watchAction,
+			Flags:   watchFlags,
+		},
 		{
 			Name:    "fmt",
 			Aliases: []string{"f"},
@@ -261,7 +283,7 @@ func main() {
 	}
 }
 
-func updateData(docsDirPath string, extandFilesPath string) error {
+func updateData(docsDirPath string, extandFilesPath string, writeOpenAPI3 bool) error {
 	const tempPlaceholder string = `"schemes": "Placeholder",`
 	const illegalStr string = `"schemes": {{ marshal .Schemes }},`
 	var filePath string = docsDirPath + "/docs.go"
@@ -286,16 +308,8 @@ func updateData(docsDirPath string, extandFilesPath string) error {
 		log.Println("json unmarshal fail, check constants in docs.go")
 		return err
 	}
-	definitionsMap := templateMap["definitions"].(map[string]interface{})
-	for _, extandsFilePath := range strings.Split(extandFilesPath, "|") {
-		err = appendDefinitions(definitionsMap, extandsFilePath)
-		if err != nil {
-			log.Println("append definitions fail, the file name: " + extandsFilePath)
-			return err
-		}
-	}
-	err = replaceType(templateMap["paths"].(map[string]interface{}), definitionsMap)
-	if err != nil {
+	extandsFilePaths := strings.Split(extandFilesPath, "|")
+	if err = applyExtandsAndThirdLib(templateMap, extandsFilePaths); err != nil {
 		return err
 	}
 
@@ -313,50 +327,227 @@ func updateData(docsDirPath string, extandFilesPath string) error {
 		log.Println("write file fail")
 		return err
 	}
+
+	if writeOpenAPI3 {
+		// docs.go's embedded JSON carries unexecuted Go-template source for
+		// Host/BasePath/Schemes (they're only filled in at server runtime),
+		// so the OpenAPI 3 document is built from swagger.json instead,
+		// which `gen.Build` already wrote with the concrete values, and the
+		// same extands/third-lib pass is re-applied to it.
+		swaggerMap, err := readSwaggerFile(docsDirPath)
+		if err != nil {
+			return err
+		}
+		if err = applyExtandsAndThirdLib(swaggerMap, extandsFilePaths); err != nil {
+			return err
+		}
+		if err = writeOpenAPI3Files(docsDirPath, swaggerMap); err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
+// applyExtandsAndThirdLib merges every extands file into doc's definitions
+// and related sections, then rewrites any `third-lib-` response schema
+// accordingly. It operates in place on doc, which must have "definitions"
+// and "paths" keys in the shape swag itself produces.
+func applyExtandsAndThirdLib(doc map[string]interface{}, extandsFilePaths []string) error {
+	definitionOrigins := map[string]string{}
+	for _, extandsFilePath := range extandsFilePaths {
+		if err := appendExtands(doc, extandsFilePath, definitionOrigins); err != nil {
+			log.Println("append definitions fail, the file name: " + extandsFilePath)
+			return err
+		}
+	}
+	definitionsMap := doc["definitions"].(map[string]interface{})
+	return replaceType(doc["paths"].(map[string]interface{}), definitionsMap)
+}
+
+// writeOpenAPI3FromSwaggerFile converts the swagger.json that `gen.Build`
+// just wrote to outputDir and writes openapi.json/openapi.yaml alongside
+// it. Used by `swag init --openapi3`, which has no in-memory document to
+// convert directly.
+func writeOpenAPI3FromSwaggerFile(outputDir string) error {
+	swaggerMap, err := readSwaggerFile(outputDir)
+	if err != nil {
+		return err
+	}
+	return writeOpenAPI3Files(outputDir, swaggerMap)
+}
+
+// readSwaggerFile reads and decodes the swagger.json that `gen.Build`
+// writes to outputDir.
+func readSwaggerFile(outputDir string) (map[string]interface{}, error) {
+	swaggerBytes, err := ioutil.ReadFile(outputDir + "/swagger.json")
+	if err != nil {
+		log.Println("open swagger.json file fail")
+		return nil, err
+	}
+	swaggerMap := make(map[string]interface{})
+	if err = json.Unmarshal(swaggerBytes, &swaggerMap); err != nil {
+		log.Println("swagger.json unmarshal fail")
+		return nil, err
+	}
+	return swaggerMap, nil
+}
+
+// writeOpenAPI3Files converts a Swagger 2.0 document to OpenAPI 3.0.x and
+// writes it as openapi.json and openapi.yaml in outputDir.
+func writeOpenAPI3Files(outputDir string, swaggerMap map[string]interface{}) error {
+	openapiMap := openapi3.Convert(swaggerMap)
+
+	jsonBytes, err := json.MarshalIndent(openapiMap, "", "\t")
+	if err != nil {
+		log.Println("openapi3 json marshalIndent fail")
+		return err
+	}
+	if err = ioutil.WriteFile(outputDir+"/openapi.json", jsonBytes, 0666); err != nil {
+		log.Println("write openapi.json fail")
+		return err
+	}
+
+	yamlBytes, err := yaml.JSONToYAML(jsonBytes)
+	if err != nil {
+		log.Println("openapi3 yaml marshal fail")
+		return err
+	}
+	if err = ioutil.WriteFile(outputDir+"/openapi.yaml", yamlBytes, 0666); err != nil {
+		log.Println("write openapi.yaml fail")
+		return err
+	}
+	return nil
+}
+
+// httpMethods are the operation keys swag may emit under a path item.
+var httpMethods = []string{"get", "put", "post", "delete", "options", "head", "patch"}
+
 func replaceType(pathsMap map[string]interface{}, definitionsMap map[string]interface{}) error {
 	const typeRefPrefix = "#/definitions/"
 	for path, pathMap := range pathsMap {
-		postMap := pathMap.(map[string]interface{})["post"]
-		responsesMap := postMap.(map[string]interface{})["responses"].(map[string]interface{})
-		for _, content := range responsesMap {
-			description := strings.Trim(content.(map[string]interface{})["description"].(string), " ")
-			if strings.Index(description, "third-lib-") != -1 {
-				schema := content.(map[string]interface{})["schema"].(map[string]interface{})
+		operations := pathMap.(map[string]interface{})
+		for _, method := range httpMethods {
+			operationMap, ok := operations[method]
+			if !ok {
+				continue
+			}
+			responses, ok := operationMap.(map[string]interface{})["responses"]
+			if !ok {
+				continue
+			}
+			responsesMap := responses.(map[string]interface{})
+			for _, content := range responsesMap {
+				description := strings.Trim(content.(map[string]interface{})["description"].(string), " ")
+				if strings.Index(description, "third-lib-") == -1 {
+					continue
+				}
+				schema, ok := content.(map[string]interface{})["schema"].(map[string]interface{})
+				if !ok {
+					continue
+				}
 				descStrs := strings.Split(description, "-")
 				if len(descStrs) < 3 {
 					log.Println("The interface third-lib data structure description format is incorrect.\nThe error interface is " + path)
 					return errors.New("update fail")
 				}
-				if _, exit := definitionsMap[descStrs[2]]; !exit {
-					log.Println(descStrs[2] + " type not exist. Please add type to the extands.json")
+				thirdLibSchema, err := buildThirdLibSchema(descStrs[2], definitionsMap)
+				if err != nil {
+					log.Println(err.Error() + ". The error interface is " + path)
 					return errors.New("update fail")
 				}
-				replaceType := typeRefPrefix + descStrs[2]
-				schema["$ref"] = replaceType
-				delete(schema, "type")
+				for k := range schema {
+					delete(schema, k)
+				}
+				for k, v := range thirdLibSchema {
+					schema[k] = v
+				}
 			}
 		}
 	}
 	return nil
 }
 
-func appendDefinitions(definitionsMap map[string]interface{}, extandsFilePath string) error {
-	extantsFileBytes, err := ioutil.ReadFile(extandsFilePath)
-	if err != nil {
-		log.Println("open extands.json file fail")
-		return err
-	}
-	var extandsMap map[string]interface{}
-	err = json.Unmarshal(extantsFileBytes, &extandsMap)
-	if err != nil {
-		log.Println("extants.json unmarshal fail")
-		return err
+// buildThirdLibSchema turns the type expression carried after the second
+// "-" of a `third-lib-` description into the JSON-Schema fragment that
+// should replace the operation's response schema. It supports a bare type
+// name (`Foo`), a slice (`[]Foo`), a string-keyed map (`map[string]Foo`) and
+// a dotted path into a nested property (`Foo.Bar`).
+func buildThirdLibSchema(typeExpr string, definitionsMap map[string]interface{}) (map[string]interface{}, error) {
+	const typeRefPrefix = "#/definitions/"
+
+	switch {
+	case strings.HasPrefix(typeExpr, "[]"):
+		inner := typeExpr[len("[]"):]
+		if err := checkDefinitionExists(inner, definitionsMap); err != nil {
+			return nil, err
+		}
+		return map[string]interface{}{
+			"type":  "array",
+			"items": map[string]interface{}{"$ref": typeRefPrefix + inner},
+		}, nil
+	case strings.HasPrefix(typeExpr, "map[string]"):
+		inner := typeExpr[len("map[string]"):]
+		if err := checkDefinitionExists(inner, definitionsMap); err != nil {
+			return nil, err
+		}
+		return map[string]interface{}{
+			"type":                 "object",
+			"additionalProperties": map[string]interface{}{"$ref": typeRefPrefix + inner},
+		}, nil
+	case strings.Contains(typeExpr, "."):
+		return buildNestedPropertySchema(typeExpr, definitionsMap)
+	default:
+		if err := checkDefinitionExists(typeExpr, definitionsMap); err != nil {
+			return nil, err
+		}
+		return map[string]interface{}{"$ref": typeRefPrefix + typeExpr}, nil
 	}
-	for modelType, content := range extandsMap {
-		definitionsMap[modelType] = content
+}
+
+// checkDefinitionExists reports an error if typeName has not been declared
+// in definitionsMap, e.g. because the extands file that should carry it
+// wasn't passed to `--extandFiles`.
+func checkDefinitionExists(typeName string, definitionsMap map[string]interface{}) error {
+	if _, exist := definitionsMap[typeName]; !exist {
+		return errors.New(typeName + " type not exist. Please add type to the extands.json")
 	}
 	return nil
 }
+
+// buildNestedPropertySchema walks a dotted path such as "Foo.Bar.Baz" into
+// the `properties` of the already-known definitions, generating a synthetic
+// definition for the nested property (e.g. "Foo_Bar_Baz") so it can be
+// referenced like any other schema.
+func buildNestedPropertySchema(typeExpr string, definitionsMap map[string]interface{}) (map[string]interface{}, error) {
+	const typeRefPrefix = "#/definitions/"
+
+	segments := strings.Split(typeExpr, ".")
+	if err := checkDefinitionExists(segments[0], definitionsMap); err != nil {
+		return nil, err
+	}
+
+	current, ok := definitionsMap[segments[0]].(map[string]interface{})
+	if !ok {
+		return nil, errors.New(segments[0] + " definition is not an object schema")
+	}
+
+	parent := segments[0]
+	for _, segment := range segments[1:] {
+		properties, ok := current["properties"].(map[string]interface{})
+		if !ok {
+			return nil, errors.New(typeExpr + ": " + parent + " has no properties to walk into")
+		}
+		property, ok := properties[segment].(map[string]interface{})
+		if !ok {
+			return nil, errors.New(typeExpr + ": property " + segment + " not found")
+		}
+		current = property
+		parent = segment
+	}
+
+	syntheticName := strings.Join(segments, "_")
+	if _, exist := definitionsMap[syntheticName]; !exist {
+		definitionsMap[syntheticName] = current
+	}
+	return map[string]interface{}{"$ref": typeRefPrefix + syntheticName}, nil
+}
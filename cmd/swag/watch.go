@@ -0,0 +1,182 @@
+package main
+
+import (
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/urfave/cli/v2"
+)
+
+const watchDebounce = 300 * time.Millisecond
+
+// watchFlags accepts the same flags as `update`, since a watch cycle is
+// just `init`/`update` re-run on every relevant source change.
+var watchFlags = updateFlags
+
+// watchDefaultExcludes are directories never worth watching, on top of
+// whatever the user passed via --exclude and the actual --output
+// directory (added separately in watchAction, since its path isn't a
+// fixed name).
+var watchDefaultExcludes = []string{"vendor", ".git"}
+
+// watchAction monitors the --dir tree (honoring --exclude) plus every
+// --extandFiles path, and re-runs `init`/`update` whenever something
+// relevant changes. A `.go` file change triggers a full re-run; an extands
+// file change only re-runs the cheap post-processing step.
+func watchAction(ctx *cli.Context) error {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+	defer watcher.Close()
+
+	excludes := buildExcludeSet(ctx.String(excludeFlag))
+	// Otherwise every docs.go/swagger.json rewrite would fire a fresh
+	// fsnotify event and re-trigger a rebuild, looping forever.
+	excludes[absPath(ctx.String(outputFlag))] = true
+	for _, searchDir := range strings.Split(ctx.String(searchDirFlag), ",") {
+		if err := addWatchTree(watcher, searchDir, excludes); err != nil {
+			return err
+		}
+	}
+
+	extandsFiles := strings.Split(ctx.String(extandFilesFlag), "|")
+	for _, extandsFilePath := range extandsFiles {
+		if err := watcher.Add(filepath.Dir(extandsFilePath)); err != nil {
+			log.Println("watch: could not watch " + extandsFilePath + ": " + err.Error())
+		}
+	}
+
+	log.Println("watch: watching " + ctx.String(searchDirFlag) + " for changes")
+
+	changed := map[string]bool{}
+	extandsOnly := true
+	var timer *time.Timer
+
+	for {
+		select {
+		case event, ok := <-watcher.Events:
+			if !ok {
+				return nil
+			}
+			if !isRelevantWatchEvent(event, extandsFiles) {
+				continue
+			}
+			changed[event.Name] = true
+			if !isExtandsPath(event.Name, extandsFiles) {
+				extandsOnly = false
+			}
+			if timer == nil {
+				timer = time.NewTimer(watchDebounce)
+			} else {
+				timer.Reset(watchDebounce)
+			}
+		case <-timerC(timer):
+			runWatchCycle(ctx, changed, extandsOnly)
+			changed = map[string]bool{}
+			extandsOnly = true
+			timer = nil
+		case watchErr, ok := <-watcher.Errors:
+			if !ok {
+				return nil
+			}
+			log.Println("watch: error: " + watchErr.Error())
+		}
+	}
+}
+
+// timerC returns timer's channel, or nil if timer hasn't been started yet.
+// A nil channel blocks forever in a select, which is exactly what's wanted
+// while no debounce window is pending.
+func timerC(timer *time.Timer) <-chan time.Time {
+	if timer == nil {
+		return nil
+	}
+	return timer.C
+}
+
+// runWatchCycle re-runs init/update for one debounce window and prints a
+// one-line summary of what happened.
+func runWatchCycle(ctx *cli.Context, changed map[string]bool, extandsOnly bool) {
+	start := time.Now()
+
+	var err error
+	if extandsOnly {
+		err = updateData(ctx.String(outputFlag), ctx.String(extandFilesFlag), ctx.Bool(openapi3Flag))
+	} else {
+		err = updateAction(ctx)
+	}
+
+	status := "ok"
+	if err != nil {
+		status = "failed: " + err.Error()
+	}
+	log.Printf("watch: %d file(s) changed, %s, %s", len(changed), time.Since(start).Round(time.Millisecond), status)
+}
+
+func isRelevantWatchEvent(event fsnotify.Event, extandsFiles []string) bool {
+	if event.Op&(fsnotify.Write|fsnotify.Create|fsnotify.Remove|fsnotify.Rename) == 0 {
+		return false
+	}
+	if isExtandsPath(event.Name, extandsFiles) {
+		return true
+	}
+	return strings.HasSuffix(event.Name, ".go")
+}
+
+func isExtandsPath(path string, extandsFiles []string) bool {
+	for _, extandsFilePath := range extandsFiles {
+		if absPath(path) == absPath(extandsFilePath) {
+			return true
+		}
+	}
+	return false
+}
+
+// absPath resolves path to an absolute, cleaned form, falling back to a
+// plain Clean if the working directory can't be determined. This lets
+// excluded/watched directories be compared reliably no matter whether the
+// user passed relative or absolute --dir/--output/--exclude values.
+func absPath(path string) string {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return filepath.Clean(path)
+	}
+	return abs
+}
+
+// buildExcludeSet turns a comma-separated --exclude value into a set of
+// absolute paths, mirroring how `gen` and `format` interpret --exclude.
+func buildExcludeSet(excludeFlagValue string) map[string]bool {
+	excludes := map[string]bool{}
+	for _, fi := range strings.Split(excludeFlagValue, ",") {
+		if fi = strings.TrimSpace(fi); fi != "" {
+			excludes[absPath(fi)] = true
+		}
+	}
+	return excludes
+}
+
+// addWatchTree registers a watch on searchDir and every subdirectory,
+// skipping anything under watchDefaultExcludes or excludes.
+func addWatchTree(watcher *fsnotify.Watcher, searchDir string, excludes map[string]bool) error {
+	for _, d := range watchDefaultExcludes {
+		excludes[absPath(filepath.Join(searchDir, d))] = true
+	}
+	return filepath.Walk(searchDir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.IsDir() {
+			return nil
+		}
+		if excludes[absPath(path)] {
+			return filepath.SkipDir
+		}
+		return watcher.Add(path)
+	})
+}
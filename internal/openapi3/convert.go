@@ -0,0 +1,387 @@
+// Package openapi3 converts the Swagger 2.0 document swag generates (after
+// any `swag update` post-processing such as third-lib response rewriting
+// and extands merging has already run against it) into an OpenAPI 3.0.x
+// document. It is intentionally narrow: it covers the shapes swag itself
+// produces rather than the whole Swagger 2.0 spec. Response headers are
+// copied as they are; their Swagger 2.0 type fields are not moved under a
+// `schema`.
+package openapi3
+
+import "strings"
+
+const defaultVersion = "3.0.3"
+
+const (
+	definitionsRefPrefix = "#/definitions/"
+	schemasRefPrefix     = "#/components/schemas/"
+)
+
+// Convert turns a Swagger 2.0 document (decoded into a generic
+// map[string]interface{}, the same shape `swag update` operates on) into an
+// OpenAPI 3.0.x document of the same shape.
+func Convert(swagger map[string]interface{}) map[string]interface{} {
+	openapi := map[string]interface{}{
+		"openapi": defaultVersion,
+	}
+
+	if info, ok := swagger["info"]; ok {
+		openapi["info"] = info
+	}
+	if tags, ok := swagger["tags"]; ok {
+		openapi["tags"] = tags
+	}
+	if externalDocs, ok := swagger["externalDocs"]; ok {
+		openapi["externalDocs"] = externalDocs
+	}
+	for key, value := range swagger {
+		if strings.HasPrefix(key, "x-") {
+			openapi[key] = value
+		}
+	}
+
+	openapi["servers"] = buildServers(swagger)
+
+	components := map[string]interface{}{}
+	if definitions, ok := swagger["definitions"].(map[string]interface{}); ok {
+		components["schemas"] = convertRefs(definitions).(map[string]interface{})
+	}
+	if securityDefinitions, ok := swagger["securityDefinitions"].(map[string]interface{}); ok {
+		components["securitySchemes"] = convertSecuritySchemes(securityDefinitions)
+	}
+	if parameters, ok := swagger["parameters"].(map[string]interface{}); ok {
+		components["parameters"] = convertRefs(parameters).(map[string]interface{})
+	}
+	if responses, ok := swagger["responses"].(map[string]interface{}); ok {
+		components["responses"] = convertRefs(responses).(map[string]interface{})
+	}
+	if len(components) > 0 {
+		openapi["components"] = components
+	}
+
+	if security, ok := swagger["security"]; ok {
+		openapi["security"] = security
+	}
+
+	consumes, _ := stringSlice(swagger["consumes"])
+	produces, _ := stringSlice(swagger["produces"])
+	if paths, ok := swagger["paths"].(map[string]interface{}); ok {
+		openapi["paths"] = convertPaths(paths, consumes, produces)
+	}
+
+	return openapi
+}
+
+func buildServers(swagger map[string]interface{}) []interface{} {
+	host, _ := swagger["host"].(string)
+	basePath, _ := swagger["basePath"].(string)
+	schemes, _ := stringSlice(swagger["schemes"])
+	if len(schemes) == 0 {
+		schemes = []string{"http"}
+	}
+
+	servers := make([]interface{}, 0, len(schemes))
+	for _, scheme := range schemes {
+		servers = append(servers, map[string]interface{}{
+			"url": scheme + "://" + host + basePath,
+		})
+	}
+	return servers
+}
+
+func stringSlice(value interface{}) ([]string, bool) {
+	raw, ok := value.([]interface{})
+	if !ok {
+		return nil, false
+	}
+	out := make([]string, 0, len(raw))
+	for _, v := range raw {
+		if s, ok := v.(string); ok {
+			out = append(out, s)
+		}
+	}
+	return out, true
+}
+
+func convertSecuritySchemes(securityDefinitions map[string]interface{}) map[string]interface{} {
+	schemes := make(map[string]interface{}, len(securityDefinitions))
+	for name, raw := range securityDefinitions {
+		definition, ok := raw.(map[string]interface{})
+		if !ok {
+			schemes[name] = raw
+			continue
+		}
+		if definition["type"] == "oauth2" {
+			schemes[name] = convertOAuth2SecurityScheme(definition)
+			continue
+		}
+		scheme := map[string]interface{}{}
+		for k, v := range definition {
+			scheme[k] = v
+		}
+		if scheme["type"] == "basic" {
+			scheme["type"] = "http"
+			scheme["scheme"] = "basic"
+		}
+		schemes[name] = scheme
+	}
+	return schemes
+}
+
+// oauth2FlowNames maps Swagger 2.0's flat `flow` values to the OpenAPI 3
+// flow object keys they nest under in `flows`.
+var oauth2FlowNames = map[string]string{
+	"implicit":    "implicit",
+	"password":    "password",
+	"application": "clientCredentials",
+	"accessCode":  "authorizationCode",
+}
+
+// convertOAuth2SecurityScheme rewrites a Swagger 2.0 oauth2 Security Scheme
+// Object, which carries flow/authorizationUrl/tokenUrl/scopes as flat
+// fields, into OpenAPI 3's shape, which nests them under
+// flows.<flowName>.
+func convertOAuth2SecurityScheme(definition map[string]interface{}) map[string]interface{} {
+	flowStr, _ := definition["flow"].(string)
+	flowName, ok := oauth2FlowNames[flowStr]
+	if !ok {
+		flowName = "implicit"
+	}
+
+	flow := map[string]interface{}{}
+	if scopes, ok := definition["scopes"]; ok {
+		flow["scopes"] = scopes
+	} else {
+		flow["scopes"] = map[string]interface{}{}
+	}
+	if authorizationUrl, ok := definition["authorizationUrl"]; ok {
+		flow["authorizationUrl"] = authorizationUrl
+	}
+	if tokenUrl, ok := definition["tokenUrl"]; ok {
+		flow["tokenUrl"] = tokenUrl
+	}
+
+	scheme := map[string]interface{}{
+		"type":  "oauth2",
+		"flows": map[string]interface{}{flowName: flow},
+	}
+	if description, ok := definition["description"]; ok {
+		scheme["description"] = description
+	}
+	return scheme
+}
+
+func convertPaths(paths map[string]interface{}, consumes, produces []string) map[string]interface{} {
+	out := make(map[string]interface{}, len(paths))
+	for path, rawItem := range paths {
+		item, ok := rawItem.(map[string]interface{})
+		if !ok {
+			out[path] = rawItem
+			continue
+		}
+		out[path] = convertPathItem(item, consumes, produces)
+	}
+	return out
+}
+
+var operationKeys = []string{"get", "put", "post", "delete", "options", "head", "patch"}
+
+func convertPathItem(item map[string]interface{}, consumes, produces []string) map[string]interface{} {
+	out := map[string]interface{}{}
+	for key, value := range item {
+		out[key] = value
+	}
+	for _, method := range operationKeys {
+		operation, ok := item[method].(map[string]interface{})
+		if !ok {
+			continue
+		}
+		out[method] = convertOperation(operation, consumes, produces)
+	}
+	return out
+}
+
+func convertOperation(operation map[string]interface{}, consumes, produces []string) map[string]interface{} {
+	out := map[string]interface{}{}
+	for key, value := range operation {
+		out[key] = value
+	}
+	if opConsumes, ok := stringSlice(operation["consumes"]); ok {
+		consumes = opConsumes
+	}
+	if opProduces, ok := stringSlice(operation["produces"]); ok {
+		produces = opProduces
+	}
+	delete(out, "consumes")
+	delete(out, "produces")
+
+	if rawParameters, ok := operation["parameters"].([]interface{}); ok {
+		parameters, requestBody := convertParameters(rawParameters, consumes)
+		if len(parameters) > 0 {
+			out["parameters"] = parameters
+		} else {
+			delete(out, "parameters")
+		}
+		if requestBody != nil {
+			out["requestBody"] = requestBody
+		}
+	}
+
+	if responses, ok := operation["responses"].(map[string]interface{}); ok {
+		out["responses"] = convertResponses(responses, produces)
+	}
+
+	return out
+}
+
+func convertParameters(rawParameters []interface{}, consumes []string) ([]interface{}, map[string]interface{}) {
+	parameters := make([]interface{}, 0, len(rawParameters))
+	var requestBody map[string]interface{}
+
+	for _, raw := range rawParameters {
+		parameter, ok := raw.(map[string]interface{})
+		if !ok {
+			parameters = append(parameters, raw)
+			continue
+		}
+		if parameter["in"] == "body" {
+			requestBody = convertBodyParameter(parameter, consumes)
+			continue
+		}
+		if parameter["in"] == "formData" {
+			// formData parameters fold into a requestBody with a form media
+			// type; accumulate them onto a single requestBody schema.
+			requestBody = mergeFormDataParameter(requestBody, parameter)
+			continue
+		}
+		converted := map[string]interface{}{}
+		fieldSchema := map[string]interface{}{}
+		for k, v := range parameter {
+			switch k {
+			case "type", "format", "items", "enum", "default", "maximum", "minimum", "maxLength", "minLength", "pattern", "maxItems", "minItems", "uniqueItems", "multipleOf":
+				// OpenAPI 3 carries a non-body parameter's type under `schema`.
+				fieldSchema[k] = v
+			default:
+				converted[k] = v
+			}
+		}
+		if schema, ok := converted["schema"]; ok {
+			converted["schema"] = convertRefs(schema)
+		} else if len(fieldSchema) > 0 {
+			converted["schema"] = convertRefs(fieldSchema)
+		}
+		parameters = append(parameters, converted)
+	}
+
+	return parameters, requestBody
+}
+
+func convertBodyParameter(parameter map[string]interface{}, consumes []string) map[string]interface{} {
+	if len(consumes) == 0 {
+		consumes = []string{"application/json"}
+	}
+	schema := convertRefs(parameter["schema"])
+	content := map[string]interface{}{}
+	for _, mediaType := range consumes {
+		content[mediaType] = map[string]interface{}{"schema": schema}
+	}
+	body := map[string]interface{}{"content": content}
+	if required, ok := parameter["required"].(bool); ok {
+		body["required"] = required
+	}
+	if description, ok := parameter["description"]; ok {
+		body["description"] = description
+	}
+	return body
+}
+
+func mergeFormDataParameter(requestBody map[string]interface{}, parameter map[string]interface{}) map[string]interface{} {
+	const mediaType = "application/x-www-form-urlencoded"
+
+	if requestBody == nil {
+		requestBody = map[string]interface{}{
+			"content": map[string]interface{}{
+				mediaType: map[string]interface{}{
+					"schema": map[string]interface{}{
+						"type":       "object",
+						"properties": map[string]interface{}{},
+					},
+				},
+			},
+		}
+	}
+
+	content := requestBody["content"].(map[string]interface{})
+	form := content[mediaType].(map[string]interface{})
+	schema := form["schema"].(map[string]interface{})
+	properties := schema["properties"].(map[string]interface{})
+
+	name, _ := parameter["name"].(string)
+	property := map[string]interface{}{}
+	for k, v := range parameter {
+		switch k {
+		case "name", "in", "required", "description":
+			continue
+		default:
+			property[k] = v
+		}
+	}
+	properties[name] = property
+
+	return requestBody
+}
+
+func convertResponses(responses map[string]interface{}, produces []string) map[string]interface{} {
+	if len(produces) == 0 {
+		produces = []string{"application/json"}
+	}
+
+	out := make(map[string]interface{}, len(responses))
+	for status, raw := range responses {
+		response, ok := raw.(map[string]interface{})
+		if !ok {
+			out[status] = raw
+			continue
+		}
+		converted := map[string]interface{}{}
+		for k, v := range response {
+			converted[k] = v
+		}
+		if schema, ok := converted["schema"]; ok {
+			delete(converted, "schema")
+			content := map[string]interface{}{}
+			for _, mediaType := range produces {
+				content[mediaType] = map[string]interface{}{"schema": convertRefs(schema)}
+			}
+			converted["content"] = content
+		}
+		out[status] = converted
+	}
+	return out
+}
+
+// convertRefs walks an arbitrary JSON value rewriting every
+// "#/definitions/..." $ref into its "#/components/schemas/..." equivalent.
+func convertRefs(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		out := make(map[string]interface{}, len(v))
+		for key, val := range v {
+			if key == "$ref" {
+				if ref, ok := val.(string); ok && strings.HasPrefix(ref, definitionsRefPrefix) {
+					out[key] = schemasRefPrefix + strings.TrimPrefix(ref, definitionsRefPrefix)
+					continue
+				}
+			}
+			out[key] = convertRefs(val)
+		}
+		return out
+	case []interface{}:
+		out := make([]interface{}, len(v))
+		for i, val := range v {
+			out[i] = convertRefs(val)
+		}
+		return out
+	default:
+		return value
+	}
+}